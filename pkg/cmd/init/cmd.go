@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const (
@@ -34,17 +35,37 @@ const (
 based on common file formats. Any files whose type (i.e. model, dataset, etc.)
 cannot be determined will be included in a code layer.
 
-By default the command will prompt for input for a name and description for the Kitfile`
+By default the command will prompt for input for a name and description for the Kitfile
+
+When run from a terminal, this command walks through the detected contents
+interactively, allowing roles, descriptions, and model metadata to be
+reviewed and adjusted before the Kitfile is written. Use --no-prompt to skip
+this and accept the detected classification as-is, or --dry-run to print
+the generated Kitfile without writing it.
+
+Paths matched by a .gitignore or .kitignore file in the scanned directory are
+skipped, and --max-code-size can be used to exclude individually oversized
+files from the generated code layer.`
 	example = `# Generate a Kitfile for the current directory:
 kit init .
 
 # Generate a Kitfile for files in ./my-model, with name "mymodel" and a description:
-kit init ./my-model --name "mymodel" --desc "This is my model's description"`
+kit init ./my-model --name "mymodel" --desc "This is my model's description"
+
+# Generate a Kitfile without the interactive wizard:
+kit init . --no-prompt
+
+# Preview the generated Kitfile without writing it:
+kit init . --dry-run`
 )
 
 type initOptions struct {
-	path       string
-	configHome string
+	path             string
+	configHome       string
+	noPrompt         bool
+	dryRun           bool
+	licenseThreshold float64
+	maxCodeSize      int64
 }
 
 func InitCommand() *cobra.Command {
@@ -58,6 +79,10 @@ func InitCommand() *cobra.Command {
 		RunE:    runCommand(opts),
 		Args:    cobra.ExactArgs(1),
 	}
+	cmd.Flags().BoolVar(&opts.noPrompt, "no-prompt", false, "Disable the interactive wizard and accept the detected classification as-is")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the generated Kitfile without writing it to disk")
+	cmd.Flags().Float64Var(&opts.licenseThreshold, "license-threshold", 0, "Minimum match coverage (0-100) for a license to be reported; defaults to a sensible built-in threshold")
+	cmd.Flags().Int64Var(&opts.maxCodeSize, "max-code-size", 0, "Maximum size in bytes of an individual file to include in a code layer; larger files are skipped instead. 0 disables the limit")
 
 	return cmd
 }
@@ -68,14 +93,38 @@ func runCommand(opts *initOptions) func(*cobra.Command, []string) error {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		kitfile, err := kitfile.GenerateKitfile(opts.path, nil)
+		detected, err := kitfile.ClassifyDirectoryWithOptions(opts.path, kitfile.ScanOptions{
+			ConfigHome:       opts.configHome,
+			LicenseThreshold: opts.licenseThreshold,
+			MaxCodeSize:      opts.maxCodeSize,
+		})
 		if err != nil {
 			return output.Fatalf("Error generating Kitfile: %s", err)
 		}
-		bytes, err := kitfile.MarshalToYAML()
+		if skipped := len(detected.SkippedPaths); skipped > 0 {
+			output.Infof("Skipped %d path(s) excluded by .gitignore/.kitignore or --max-code-size", skipped)
+		}
+
+		if opts.interactive() {
+			if err := runWizard(detected); err != nil {
+				return output.Fatalf("Error running interactive wizard: %s", err)
+			}
+		}
+
+		kf, err := kitfile.BuildKitfile(detected, nil)
+		if err != nil {
+			return output.Fatalf("Error generating Kitfile: %s", err)
+		}
+		bytes, err := kf.MarshalToYAML()
 		if err != nil {
 			return output.Fatalf("Error formatting Kitfile: %s", err)
 		}
+
+		if opts.dryRun {
+			output.Infof("Generated Kitfile (dry run, not written):\n\n%s", string(bytes))
+			return nil
+		}
+
 		kitfilePath := filepath.Join(opts.path, constants.DefaultKitfileName)
 		if err := os.WriteFile(kitfilePath, bytes, 0644); err != nil {
 			return output.Fatalf("Failed to write Kitfile: %s", err)
@@ -86,6 +135,15 @@ func runCommand(opts *initOptions) func(*cobra.Command, []string) error {
 	}
 }
 
+// interactive returns true if the wizard should run: the user has not
+// disabled it with --no-prompt, and stdin is a terminal we can prompt on.
+func (opts *initOptions) interactive() bool {
+	if opts.noPrompt {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
 func (opts *initOptions) complete(ctx context.Context, args []string) error {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
@@ -0,0 +1,224 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitinit
+
+import (
+	"bufio"
+	"fmt"
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/kitfile"
+	"kitops/pkg/output"
+	"os"
+	"strings"
+)
+
+// runWizard walks the user through the contents detected by
+// kitfile.ClassifyDirectory, letting them reassign each model, dataset, or
+// code path to one of those three roles, edit docs descriptions, and fill
+// in model metadata before the Kitfile is built. It mutates detected in
+// place.
+func runWizard(detected *kitfile.DetectedContents) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	output.Infof("Reviewing detected contents of '%s'. Press enter to accept a suggestion.", detected.BaseDir)
+
+	// Reassignments are collected here rather than applied to detected as
+	// each group is reviewed, so that a path reassigned forward (e.g. a
+	// model file demoted to code) doesn't land in a later group's slice
+	// before that group's review has started and get prompted a second
+	// time.
+	pending := &pendingReassignments{}
+	reviewGroup(reader, pending, "model", &detected.ModelFiles)
+	reviewDatasetGroup(reader, pending, detected)
+	reviewCodeGroup(reader, pending, detected)
+	pending.apply(detected)
+
+	reviewDocsGroup(reader, detected)
+
+	if len(detected.ModelFiles) > 0 {
+		if err := reviewModelMetadata(reader, detected); err != nil {
+			return err
+		}
+	}
+
+	if err := reviewTags(reader, detected); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reassignableRoles are the roles reviewRole offers as a destination when a
+// path is reassigned away from its detected role. Docs aren't included:
+// they carry a description rather than a license, so they're reviewed
+// separately by reviewDocsGroup.
+const reassignableRoles = "model/dataset/code"
+
+// pendingReassignments collects paths reassigned away from their detected
+// role while reviewGroup/reviewDatasetGroup/reviewCodeGroup run, so they can
+// be merged into detected once every group has been reviewed rather than as
+// each reassignment happens. Applying eagerly would grow a later group's
+// slice mid-review (e.g. a model file demoted to code landing in
+// detected.CodePaths before reviewCodeGroup started) and prompt for that
+// path a second time.
+type pendingReassignments struct {
+	toModel   []string
+	toDataset []artifact.DataSet
+	toCode    []kitfile.ClassifiedPath
+}
+
+func (p *pendingReassignments) add(role, path, license string) {
+	switch role {
+	case "model":
+		p.toModel = append(p.toModel, path)
+	case "dataset":
+		p.toDataset = append(p.toDataset, artifact.DataSet{Path: path, License: license})
+	case "code":
+		p.toCode = append(p.toCode, kitfile.ClassifiedPath{Path: path, License: license})
+	}
+}
+
+func (p *pendingReassignments) apply(detected *kitfile.DetectedContents) {
+	detected.ModelFiles = append(detected.ModelFiles, p.toModel...)
+	detected.Datasets = append(detected.Datasets, p.toDataset...)
+	detected.CodePaths = append(detected.CodePaths, p.toCode...)
+}
+
+// reviewRole asks the user whether to keep path under its detected role, or
+// reassign it to a different one (model, dataset, or code). It returns the
+// target role if reassigned, or "" if the path should remain under
+// currentRole.
+func reviewRole(reader *bufio.Reader, path, currentRole string) (targetRole string) {
+	for {
+		choice := strings.ToLower(promptString(reader, fmt.Sprintf("  '%s' detected as %s; keep, or reassign to [%s]", path, currentRole, reassignableRoles), "keep"))
+		switch choice {
+		case "keep", currentRole:
+			return ""
+		case "model", "dataset", "code":
+			return choice
+		default:
+			output.Infof("  '%s' isn't a valid role; choose one of keep/%s", choice, reassignableRoles)
+		}
+	}
+}
+
+// reviewGroup walks a slice of detected paths for a single role, letting the
+// user reassign any that were misclassified to a different role.
+func reviewGroup(reader *bufio.Reader, pending *pendingReassignments, role string, paths *[]string) {
+	if len(*paths) == 0 {
+		return
+	}
+	output.Infof("Detected %s files/directories:", role)
+	var kept []string
+	for _, path := range *paths {
+		if target := reviewRole(reader, path, role); target == "" {
+			kept = append(kept, path)
+		} else {
+			pending.add(target, path, "")
+		}
+	}
+	*paths = kept
+}
+
+// reviewCodeGroup walks the detected code paths, letting the user reassign
+// any that were misclassified. Unlike reviewGroup, code paths carry a
+// detected license alongside the path, so they're reviewed separately.
+func reviewCodeGroup(reader *bufio.Reader, pending *pendingReassignments, detected *kitfile.DetectedContents) {
+	if len(detected.CodePaths) == 0 {
+		return
+	}
+	output.Infof("Detected code files/directories:")
+	kept := detected.CodePaths[:0:0]
+	for _, cp := range detected.CodePaths {
+		if target := reviewRole(reader, cp.Path, "code"); target == "" {
+			kept = append(kept, cp)
+		} else {
+			pending.add(target, cp.Path, cp.License)
+		}
+	}
+	detected.CodePaths = kept
+}
+
+func reviewDatasetGroup(reader *bufio.Reader, pending *pendingReassignments, detected *kitfile.DetectedContents) {
+	if len(detected.Datasets) == 0 {
+		return
+	}
+	output.Infof("Detected dataset files/directories:")
+	keptDatasets := detected.Datasets[:0:0]
+	for _, ds := range detected.Datasets {
+		if target := reviewRole(reader, ds.Path, "dataset"); target == "" {
+			keptDatasets = append(keptDatasets, ds)
+		} else {
+			pending.add(target, ds.Path, ds.License)
+		}
+	}
+	detected.Datasets = keptDatasets
+}
+
+func reviewDocsGroup(reader *bufio.Reader, detected *kitfile.DetectedContents) {
+	if len(detected.Docs) == 0 {
+		return
+	}
+	output.Infof("Detected documentation files/directories:")
+	for i := range detected.Docs {
+		doc := &detected.Docs[i]
+		desc := promptString(reader, fmt.Sprintf("  description for '%s'", doc.Path), doc.Description)
+		doc.Description = desc
+	}
+}
+
+func reviewModelMetadata(reader *bufio.Reader, detected *kitfile.DetectedContents) error {
+	output.Infof("Model metadata:")
+	detected.ModelName = promptString(reader, "  model name", detected.ModelName)
+	detected.ModelFramework = promptString(reader, "  model framework", detected.ModelFramework)
+	detected.ModelVersion = promptString(reader, "  model version", detected.ModelVersion)
+	return nil
+}
+
+// reviewTags lets the user pick a tag to use when packing the generated
+// Kitfile. The Kitfile format has no tags field of its own, so the chosen
+// value is only echoed back as a suggestion for `kit pack -t`.
+func reviewTags(reader *bufio.Reader, detected *kitfile.DetectedContents) error {
+	suggested := detected.ModelName
+	if suggested == "" {
+		suggested = "my-model"
+	}
+	if detected.ModelVersion != "" {
+		suggested = suggested + ":" + detected.ModelVersion
+	} else {
+		suggested = suggested + ":latest"
+	}
+	tag := promptString(reader, "  tag to use when packing (kit pack -t)", suggested)
+	if tag != "" {
+		output.Infof("  remember to run 'kit pack %s -t %s' once the Kitfile is saved", detected.BaseDir, tag)
+	}
+	return nil
+}
+
+func promptString(reader *bufio.Reader, prompt, defaultVal string) string {
+	if defaultVal != "" {
+		fmt.Printf("%s [%s]: ", prompt, defaultVal)
+	} else {
+		fmt.Printf("%s: ", prompt)
+	}
+	line, _ := reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultVal
+	}
+	return line
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package classify
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+var modelWeightsSuffixes = []string{
+	".safetensors", ".pkl", ".joblib",
+	// Pytorch suffixes
+	".bin", ".pth", ".pt", ".mar", ".pt2", ".ptl",
+	// Tensorflow
+	".pb", ".ckpt", ".tflite", ".tfrecords",
+	// NumPy
+	".npy", ".npz",
+	// Keras and others
+	".keras", ".h5", ".caffemodel", ".pmml", ".coreml",
+	// Other suffixes
+	".gguf", ".ggml", ".ggmf", ".llamafile", ".onnx",
+}
+
+var docsSuffixes = []string{
+	".md", ".adoc", ".html", ".pdf",
+}
+
+var metadataSuffixes = []string{
+	".json", ".yaml", ".xml", ".txt",
+}
+
+var datasetSuffixes = []string{
+	".tar", ".zip", ".parquet", ".csv",
+}
+
+// builtinClassifiers returns the classifiers kit init has always applied:
+// suffix-based matching for each role, plus magic-byte detectors for
+// common model and dataset formats that catch files without (or with
+// misleading) extensions. Suffix classifiers are checked first, since
+// they're cheap and unambiguous; magic-byte classifiers only run when no
+// suffix matched.
+func builtinClassifiers() []Classifier {
+	return []Classifier{
+		suffixClassifier{role: RoleModel, suffixes: modelWeightsSuffixes},
+		suffixClassifier{role: RoleMetadata, suffixes: metadataSuffixes},
+		suffixClassifier{role: RoleDocs, suffixes: docsSuffixes},
+		suffixClassifier{role: RoleDataset, suffixes: datasetSuffixes},
+		magicClassifier{role: RoleModel, magic: []byte(ggufMagic)},
+		magicClassifier{role: RoleModel, magic: []byte(hdf5Magic)},
+		magicClassifier{role: RoleDataset, magic: []byte(parquetMagic)},
+		safetensorsClassifier{},
+		onnxClassifier{},
+	}
+}
+
+const (
+	ggufMagic    = "GGUF"
+	hdf5Magic    = "\x89HDF\r\n\x1a\n"
+	parquetMagic = "PAR1"
+)
+
+// suffixClassifier matches files by filename suffix, the original (and
+// still primary) way kit init classifies files.
+type suffixClassifier struct {
+	role     Role
+	suffixes []string
+}
+
+func (s suffixClassifier) Role() Role { return s.role }
+
+func (s suffixClassifier) Match(path string, _ []byte) bool {
+	return anySuffix(path, s.suffixes)
+}
+
+func anySuffix(path string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// magicClassifier matches files whose header starts with a fixed byte
+// sequence, for formats with misleading or absent extensions.
+type magicClassifier struct {
+	role  Role
+	magic []byte
+}
+
+func (m magicClassifier) Role() Role { return m.role }
+
+func (m magicClassifier) Match(_ string, header []byte) bool {
+	return bytes.HasPrefix(header, m.magic)
+}
+
+// safetensorsClassifier recognizes .safetensors files that lack their
+// usual extension: the format has no fixed magic bytes, but always starts
+// with a little-endian uint64 header length followed by a '{', the start
+// of the JSON header.
+type safetensorsClassifier struct{}
+
+func (safetensorsClassifier) Role() Role { return RoleModel }
+
+func (safetensorsClassifier) Match(_ string, header []byte) bool {
+	return IsSafetensorsHeader(header)
+}
+
+// onnxClassifier recognizes .onnx files that lack their usual extension.
+// ONNX ModelProto messages conventionally set their first field
+// (ir_version, a varint) before anything else, so well-formed files
+// usually start with that field's tag byte (field 1, wire type 0). This is
+// a heuristic, not a true magic number, so it's only consulted after every
+// other classifier has failed to match.
+type onnxClassifier struct{}
+
+func (onnxClassifier) Role() Role { return RoleModel }
+
+func (onnxClassifier) Match(path string, header []byte) bool {
+	if filepath.Ext(path) != "" {
+		// Only apply this weak heuristic to extensionless files; it's too
+		// prone to false positives to override an unrelated extension.
+		return false
+	}
+	return len(header) >= 2 && header[0] == 0x08
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package classify decides what role (model, dataset, code, docs,
+// metadata, or ignore) a file plays within a Kitfile, via a pluggable
+// registry of Classifiers. Built-in classifiers reproduce kit init's
+// historical suffix-based behavior plus a handful of magic-byte detectors;
+// callers can extend or override them with user-defined rules loaded from
+// a YAML file.
+package classify
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+// Role is the kind of content a file was classified as.
+type Role string
+
+const (
+	RoleModel    Role = "model"
+	RoleDataset  Role = "dataset"
+	RoleCode     Role = "code"
+	RoleDocs     Role = "docs"
+	RoleMetadata Role = "metadata"
+	// RoleIgnore marks a file that should be excluded from the Kitfile
+	// entirely, rather than falling back to the code catch-all.
+	RoleIgnore Role = "ignore"
+	// RoleUnknown is returned when no classifier recognizes a file.
+	RoleUnknown Role = "unknown"
+)
+
+// sniffLen is how many header bytes are read from a file to support
+// magic-byte and MIME-sniffing classifiers. 512 matches the amount
+// net/http.DetectContentType considers.
+const sniffLen = 512
+
+// Classifier decides whether a file matches some criteria, and if so, what
+// Role it should be classified as.
+type Classifier interface {
+	// Match reports whether this classifier recognizes the file, given its
+	// path (relative to the scan root) and a header of up to sniffLen
+	// bytes read from its start. header is nil if the file's contents
+	// couldn't be read.
+	Match(path string, header []byte) bool
+	Role() Role
+}
+
+// ReadHeader reads up to sniffLen bytes from the start of path, returning
+// nil if the file can't be opened or read.
+func ReadHeader(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, err := f.Read(buf)
+	if err != nil && n == 0 {
+		return nil
+	}
+	return buf[:n]
+}
+
+// SafetensorsMaxHeaderLen bounds the little-endian header length prefix a
+// .safetensors file starts with; sanity bound, real headers are KBs. Shared
+// by safetensorsClassifier here and kitfile.applySafetensorsHeader, which
+// parses the same header further once a file's role is already known.
+const SafetensorsMaxHeaderLen = 100 * 1024 * 1024
+
+// IsSafetensorsHeader reports whether header (the first bytes of a file)
+// looks like the start of a .safetensors file: a little-endian uint64
+// header length, within SafetensorsMaxHeaderLen, followed by '{', the start
+// of the JSON header. The format has no fixed magic bytes of its own.
+func IsSafetensorsHeader(header []byte) bool {
+	if len(header) < 9 {
+		return false
+	}
+	headerLen := binary.LittleEndian.Uint64(header[:8])
+	return headerLen > 0 && headerLen <= SafetensorsMaxHeaderLen && header[8] == '{'
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package classify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserRulesFilename is the name of the optional file, under
+// "$configHome/kitinit/", from which additional classification rules are
+// loaded.
+const UserRulesFilename = "classifiers.yaml"
+
+// Classification is the outcome of matching a file against a Registry.
+type Classification struct {
+	Role Role
+	// Framework and Description are populated when the match came from a
+	// user-defined rule that set them; builtin classifiers never set them.
+	Framework   string
+	Description string
+}
+
+// classifierMetadata is implemented by classifiers that carry additional
+// informational metadata about a match. Only user-defined rules currently
+// do.
+type classifierMetadata interface {
+	Framework() string
+	Description() string
+}
+
+// Registry holds an ordered list of Classifiers. The first one whose
+// criteria match a file decides its Role.
+type Registry struct {
+	classifiers []Classifier
+}
+
+// NewRegistry returns a Registry that checks userClassifiers (if any) ahead
+// of kit init's built-in classifiers, so that user-defined rules can
+// override the built-in classification for a file, not just add new
+// detections.
+func NewRegistry(userClassifiers ...Classifier) *Registry {
+	classifiers := make([]Classifier, 0, len(userClassifiers)+8)
+	classifiers = append(classifiers, userClassifiers...)
+	classifiers = append(classifiers, builtinClassifiers()...)
+	return &Registry{classifiers: classifiers}
+}
+
+// Classify decides the Role of the file at fsPath (used to read its
+// header for magic-byte/MIME rules) whose path relative to the scan root
+// is relPath (used for glob/suffix/regex rules). It returns RoleUnknown if
+// no classifier matches.
+func (r *Registry) Classify(fsPath, relPath string) Classification {
+	header := ReadHeader(fsPath)
+	for _, c := range r.classifiers {
+		if !c.Match(relPath, header) {
+			continue
+		}
+		result := Classification{Role: c.Role()}
+		if m, ok := c.(classifierMetadata); ok {
+			result.Framework = m.Framework()
+			result.Description = m.Description()
+		}
+		return result
+	}
+	return Classification{Role: RoleUnknown}
+}
+
+// LoadRegistry returns a Registry built from kit init's built-in
+// classifiers plus any user-defined rules found at
+// "<configHome>/kitinit/classifiers.yaml". If configHome is empty or the
+// file doesn't exist, the returned Registry only applies the built-ins.
+func LoadRegistry(configHome string) (*Registry, error) {
+	if configHome == "" {
+		return NewRegistry(), nil
+	}
+	rulesPath := filepath.Join(configHome, "kitinit", UserRulesFilename)
+	data, err := os.ReadFile(rulesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewRegistry(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read classifier rules %s: %w", rulesPath, err)
+	}
+
+	var file ruleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier rules %s: %w", rulesPath, err)
+	}
+	rules := make([]Classifier, 0, len(file.Rules))
+	for i, cfg := range file.Rules {
+		rule, err := parseRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d in %s: %w", i, rulesPath, err)
+		}
+		rules = append(rules, rule)
+	}
+	return NewRegistry(rules...), nil
+}
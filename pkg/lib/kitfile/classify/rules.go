@@ -0,0 +1,151 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package classify
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+)
+
+// ruleFile is the top-level shape of a user-defined classifiers.yaml.
+type ruleFile struct {
+	Rules []ruleConfig `yaml:"rules"`
+}
+
+// ruleConfig is a single user-defined classification rule, as read from
+// classifiers.yaml. A file matches the rule if every criterion that is set
+// matches; at least one criterion must be set.
+type ruleConfig struct {
+	Role string `yaml:"role"`
+
+	Glob      string   `yaml:"glob"`
+	Suffix    []string `yaml:"suffix"`
+	MIME      string   `yaml:"mime"`
+	Magic     string   `yaml:"magic"` // hex-encoded, e.g. "47474655" for "GGUF"
+	PathRegex string   `yaml:"pathRegex"`
+
+	Framework   string `yaml:"framework"`
+	Description string `yaml:"description"`
+}
+
+// userRule is a Classifier built from a ruleConfig loaded from
+// classifiers.yaml.
+type userRule struct {
+	role Role
+
+	glob      string
+	suffixes  []string
+	mime      string
+	magic     []byte
+	pathRegex *regexp.Regexp
+
+	framework   string
+	description string
+}
+
+func (u *userRule) Role() Role          { return u.role }
+func (u *userRule) Framework() string   { return u.framework }
+func (u *userRule) Description() string { return u.description }
+
+// Match reports whether path and header satisfy every criterion set on the
+// rule. A rule with no criteria never matches; parseRule rejects those
+// before they reach here.
+func (u *userRule) Match(path string, header []byte) bool {
+	matched := false
+	if u.glob != "" {
+		ok, err := filepath.Match(u.glob, path)
+		if err != nil || !ok {
+			return false
+		}
+		matched = true
+	}
+	if len(u.suffixes) > 0 {
+		if !anySuffix(path, u.suffixes) {
+			return false
+		}
+		matched = true
+	}
+	if u.mime != "" {
+		if header == nil || http.DetectContentType(header) != u.mime {
+			return false
+		}
+		matched = true
+	}
+	if len(u.magic) > 0 {
+		if !bytes.HasPrefix(header, u.magic) {
+			return false
+		}
+		matched = true
+	}
+	if u.pathRegex != nil {
+		if !u.pathRegex.MatchString(path) {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// parseRule validates and compiles a single ruleConfig into a Classifier.
+func parseRule(cfg ruleConfig) (Classifier, error) {
+	role, err := ParseRole(cfg.Role)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Glob == "" && len(cfg.Suffix) == 0 && cfg.MIME == "" && cfg.Magic == "" && cfg.PathRegex == "" {
+		return nil, fmt.Errorf("rule has no match criteria (glob, suffix, mime, magic, or pathRegex)")
+	}
+
+	rule := &userRule{
+		role:        role,
+		glob:        cfg.Glob,
+		suffixes:    cfg.Suffix,
+		mime:        cfg.MIME,
+		framework:   cfg.Framework,
+		description: cfg.Description,
+	}
+	if cfg.Magic != "" {
+		magic, err := hex.DecodeString(cfg.Magic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid magic bytes %q: %w", cfg.Magic, err)
+		}
+		rule.magic = magic
+	}
+	if cfg.PathRegex != "" {
+		re, err := regexp.Compile(cfg.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pathRegex %q: %w", cfg.PathRegex, err)
+		}
+		rule.pathRegex = re
+	}
+	return rule, nil
+}
+
+// ParseRole parses the string form of a Role used in classifiers.yaml,
+// returning an error if it isn't one of the recognized roles.
+func ParseRole(s string) (Role, error) {
+	switch Role(s) {
+	case RoleModel, RoleDataset, RoleCode, RoleDocs, RoleMetadata, RoleIgnore:
+		return Role(s), nil
+	default:
+		return "", fmt.Errorf("unrecognized role %q", s)
+	}
+}
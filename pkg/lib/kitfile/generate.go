@@ -18,64 +18,96 @@ package kitfile
 
 import (
 	"fmt"
-	"io/fs"
 	"kitops/pkg/artifact"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/kitfile/classify"
+	"kitops/pkg/lib/kitfile/ignore"
+	"kitops/pkg/lib/kitfile/license"
 	"kitops/pkg/output"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/google/licensecheck"
 )
 
-type fileType int
+// DetectedContents holds the result of scanning a directory for model,
+// dataset, code, and documentation files, before it is assembled into a
+// Kitfile. It is exposed so that interactive callers (e.g. "kit init"'s
+// review wizard) can inspect and adjust the detected classification before
+// BuildKitfile turns it into a Kitfile.
+type DetectedContents struct {
+	BaseDir string
 
-const (
-	fileTypeModel fileType = iota
-	fileTypeDataset
-	fileTypeCode
-	fileTypeDocs
-	fileTypeMetadata
-	fileTypeUnknown
-)
+	ModelFiles    []string
+	MetadataPaths []string
+	Datasets      []artifact.DataSet
+	Docs          []artifact.Docs
+	CodePaths     []ClassifiedPath
+	UseCatchall   bool
 
-var modelWeightsSuffixes = []string{
-	".safetensors", ".pkl", ".joblib",
-	// Pytorch suffixes
-	".bin", ".pth", ".pt", ".mar", ".pt2", ".ptl",
-	// Tensorflow
-	".pb", ".ckpt", ".tflite", ".tfrecords",
-	// NumPy
-	".npy", ".npz",
-	// Keras and others
-	".keras", ".h5", ".caffemodel", ".pmml", ".coreml",
-	// Other suffixes
-	".gguf", ".ggml", ".ggmf", ".llamafile", ".onnx",
-}
+	// ModelLicense is the nearest license detected for a subdirectory whose
+	// contents were folded into ModelFiles, so that it can be attached to
+	// the model layer once it's assembled in BuildKitfile.
+	ModelLicense string
+
+	// LicenseCandidates collects the paths of LICENSE/COPYING/NOTICE-like
+	// files found anywhere in the tree, including nested directories, for
+	// callers that want to inspect license coverage beyond the root file.
+	LicenseCandidates []string
 
-var docsSuffixes = []string{
-	".md", ".adoc", ".html", ".pdf",
+	DetectedLicense string
+
+	// ModelName, ModelFramework, and ModelVersion, if set, override the
+	// values that would otherwise be derived automatically when the model
+	// is added to the Kitfile.
+	ModelName      string
+	ModelFramework string
+	ModelVersion   string
+
+	// SkippedPaths collects every path excluded from the generated Kitfile
+	// rather than classified, whether because it matched a .gitignore or
+	// .kitignore rule or because it exceeded --max-code-size, so that
+	// callers can report a summary of what was left out.
+	SkippedPaths []SkippedPath
 }
 
-var metadataSuffixes = []string{
-	".json", ".yaml", ".xml", ".txt",
+// ScanOptions configures how ClassifyDirectoryWithOptions detects licenses
+// and which files it excludes.
+type ScanOptions struct {
+	// ConfigHome is used to cache the SPDX license list, under
+	// "<ConfigHome>/licenses". If empty, the license list is not cached or
+	// consulted, but license detection still works using licensecheck's
+	// builtin corpus.
+	ConfigHome string
+	// LicenseThreshold is the minimum match coverage (0-100) for a license
+	// to be reported. Non-positive uses license.DefaultThreshold.
+	LicenseThreshold float64
+	// MaxCodeSize, if positive, excludes unrecognized files above this size
+	// (in bytes) from the code catch-all entirely, rather than bundling
+	// them in; they're reported in DetectedContents.SkippedPaths instead.
+	MaxCodeSize int64
 }
 
-var datasetSuffixes = []string{
-	".tar", ".zip", ".parquet", ".csv",
+// ClassifyDirectory scans baseDir with the default ScanOptions. See
+// ClassifyDirectoryWithOptions.
+func ClassifyDirectory(baseDir string) (*DetectedContents, error) {
+	return ClassifyDirectoryWithOptions(baseDir, ScanOptions{})
 }
 
-// Generate a basic Kitfile by looking at the contents of a directory. Parameter
-// packageOpt can be used to define metadata for the Kitfile (i.e. the package
-// section), which is left empty if the parameter is nil.
-func GenerateKitfile(baseDir string, packageOpt *artifact.Package) (*artifact.KitFile, error) {
+// ClassifyDirectoryWithOptions scans baseDir and returns the detected
+// contents without assembling a Kitfile. This is the seam used by
+// interactive callers (e.g. "kit init"'s review wizard) that want to review
+// or edit the classification before calling BuildKitfile.
+func ClassifyDirectoryWithOptions(baseDir string, opts ScanOptions) (*DetectedContents, error) {
 	output.Logf(output.LogLevelTrace, "Generating Kitfile in %s", baseDir)
-	kitfile := &artifact.KitFile{
-		ManifestVersion: "1.0.0",
+	dc := &DetectedContents{BaseDir: baseDir}
+	catalog := license.NewCatalog(licenseCacheDir(opts.ConfigHome), opts.LicenseThreshold)
+	registry, err := classify.LoadRegistry(opts.ConfigHome)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load classifier rules: %w", err)
 	}
-	if packageOpt != nil {
-		kitfile.Package = *packageOpt
+	ignoreSet, err := ignore.Load(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load .gitignore/.kitignore: %w", err)
 	}
 
 	output.Logf(output.LogLevelTrace, "Reading directory contents")
@@ -83,15 +115,10 @@ func GenerateKitfile(baseDir string, packageOpt *artifact.Package) (*artifact.Ki
 	if err != nil {
 		return nil, fmt.Errorf("error reading directory: %w", err)
 	}
-	// We can make sure all files are included by including a layer with path '.'
-	// However, we only want to do this if it is necessary
-	includeCatchallSection := false
-	// Dirs we don't know how to handle automatically.
-	var unprocessedDirPaths []string
-	// Metadata files; we want these to be either model parts (if there is a model)
-	// or datasets
-	var modelFiles, metadataPaths []string
-	var detectedLicenseType string
+	// Bounded worker pool shared across the whole recursive walk, so that
+	// directories at every depth are classified concurrently without
+	// spawning unbounded goroutines (and file descriptors) on wide trees.
+	sem := make(chan struct{}, maxConcurrentScans)
 	for _, d := range ds {
 		filename := d.Name()
 		if constants.IsDefaultKitfileName(filename) {
@@ -100,197 +127,170 @@ func GenerateKitfile(baseDir string, packageOpt *artifact.Package) (*artifact.Ki
 			// either way.
 			continue
 		}
+		if ignoreSet.Match(filename, d.IsDir()) {
+			output.Logf(output.LogLevelTrace, "Skipping %s: matched by .gitignore/.kitignore", filename)
+			dc.SkippedPaths = append(dc.SkippedPaths, SkippedPath{Path: filename, Reason: "matched by .gitignore/.kitignore"})
+			continue
+		}
 		if d.IsDir() {
-			dirModelFiles, err := addDirToKitfile(kitfile, baseDir, filename, d)
+			child, err := classifyTree(baseDir, filename, sem, catalog, registry, ignoreSet, opts.MaxCodeSize)
 			if err != nil {
-				output.Logf(output.LogLevelTrace, "Failed to determine type for directory %s: %s", filename, err)
-				unprocessedDirPaths = append(unprocessedDirPaths, filename)
+				output.Logf(output.LogLevelTrace, "Failed to read directory %s: %s", filename, err)
+				dc.CodePaths = append(dc.CodePaths, ClassifiedPath{Path: filename})
+				continue
 			}
-			modelFiles = append(modelFiles, dirModelFiles...)
+			mergeClassifiedDir(dc, child)
 			continue
 		}
 
 		// Check for "special" files (e.g. readme, license)
 		if strings.HasPrefix(strings.ToLower(filename), "readme") {
 			output.Logf(output.LogLevelTrace, "Found readme file '%s'", filename)
-			kitfile.Docs = append(kitfile.Docs, artifact.Docs{
+			dc.Docs = append(dc.Docs, artifact.Docs{
 				Path:        filename,
 				Description: "Readme file",
 			})
 			continue
-		} else if strings.HasPrefix(strings.ToLower(filename), "license") {
+		} else if license.IsLicenseFilename(filename) {
 			output.Logf(output.LogLevelTrace, "Found license file '%s'", filename)
-			kitfile.Docs = append(kitfile.Docs, artifact.Docs{
+			dc.Docs = append(dc.Docs, artifact.Docs{
 				Path:        filename,
 				Description: "License file",
 			})
-			licenseType, err := detectLicense(filepath.Join(baseDir, filename))
+			dc.LicenseCandidates = append(dc.LicenseCandidates, filename)
+			result, err := catalog.ScanFile(filepath.Join(baseDir, filename))
 			if err != nil {
 				output.Debugf("Error determining license type: %s", err)
 				output.Logf(output.LogLevelWarn, "Unable to determine license type")
+			} else if result.Expression != "" {
+				dc.DetectedLicense = result.Expression
 			}
-			detectedLicenseType = licenseType
-			output.Logf(output.LogLevelTrace, "Detected license %s for license file", detectedLicenseType)
+			output.Logf(output.LogLevelTrace, "Detected license %s for license file", dc.DetectedLicense)
 			continue
 		}
 
-		// Try to determine type based on file extension
-		// To support multi-part models, we need to collect all paths and decide
-		// which one is the model and which one(s) are parts
-		switch determineFileType(filename) {
-		case fileTypeModel:
-			modelFiles = append(modelFiles, filename)
-		case fileTypeMetadata:
+		// Classify based on the registry's rules (suffix, magic bytes, and any
+		// user-defined overrides). To support multi-part models, we need to
+		// collect all paths and decide which one is the model and which
+		// one(s) are parts.
+		switch result := registry.Classify(filepath.Join(baseDir, filename), filename); result.Role {
+		case classify.RoleModel:
+			dc.ModelFiles = append(dc.ModelFiles, filename)
+		case classify.RoleMetadata:
 			// Metadata should be included in either Model or Datasets, depending on
 			// other contents
 			output.Logf(output.LogLevelTrace, "Detected metadata file '%s'", filename)
-			metadataPaths = append(metadataPaths, filename)
-		case fileTypeDocs:
-			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: filename})
-		case fileTypeDataset:
-			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: filename})
+			dc.MetadataPaths = append(dc.MetadataPaths, filename)
+		case classify.RoleDocs:
+			dc.Docs = append(dc.Docs, artifact.Docs{Path: filename})
+		case classify.RoleDataset:
+			dc.Datasets = append(dc.Datasets, artifact.DataSet{Path: filename})
+		case classify.RoleIgnore:
+			output.Logf(output.LogLevelTrace, "Ignoring file %s", filename)
 		default:
+			if opts.MaxCodeSize > 0 {
+				if info, err := d.Info(); err == nil && info.Size() > opts.MaxCodeSize {
+					output.Logf(output.LogLevelTrace, "Skipping %s: exceeds --max-code-size (%d bytes)", filename, info.Size())
+					dc.SkippedPaths = append(dc.SkippedPaths, SkippedPath{Path: filename, Reason: "exceeds --max-code-size"})
+					continue
+				}
+			}
 			output.Logf(output.LogLevelTrace, "File %s is either code or unknown type. Will be added as a catch-all section", filename)
 			// File is either code or unknown; we'll have to include it in a catch-all section
-			includeCatchallSection = true
+			dc.UseCatchall = true
 		}
 	}
 
-	if len(modelFiles) > 0 {
-		if err := addModelToKitfile(kitfile, baseDir, modelFiles); err != nil {
+	return dc, nil
+}
+
+// BuildKitfile assembles a Kitfile from previously-detected contents, which
+// may have already been reviewed or edited by a caller (e.g. "kit init"'s
+// review wizard). Parameter packageOpt can be used to define metadata for
+// the Kitfile (i.e. the package section), which is left empty if the
+// parameter is nil.
+func BuildKitfile(dc *DetectedContents, packageOpt *artifact.Package) (*artifact.KitFile, error) {
+	kitfile := &artifact.KitFile{
+		ManifestVersion: "1.0.0",
+	}
+	if packageOpt != nil {
+		kitfile.Package = *packageOpt
+	}
+	kitfile.Docs = dc.Docs
+	kitfile.DataSets = dc.Datasets
+
+	if len(dc.ModelFiles) > 0 {
+		if err := addModelToKitfile(kitfile, dc.BaseDir, dc.ModelFiles); err != nil {
 			return nil, fmt.Errorf("failed to add model to Kitfile: %w", err)
 		}
 		output.Logf(output.LogLevelTrace, "Adding metadata files as model parts")
-		for _, metadataPath := range metadataPaths {
+		for _, metadataPath := range dc.MetadataPaths {
 			kitfile.Model.Parts = append(kitfile.Model.Parts, artifact.ModelPart{Path: metadataPath})
 		}
+		detectModelMetadata(dc.BaseDir, kitfile.Model, dc.ModelFiles)
+		if dc.ModelLicense != "" && kitfile.Model.License == "" {
+			kitfile.Model.License = dc.ModelLicense
+		}
+		if dc.ModelName != "" {
+			kitfile.Model.Name = dc.ModelName
+		}
+		if dc.ModelFramework != "" {
+			kitfile.Model.Framework = dc.ModelFramework
+		}
+		if dc.ModelVersion != "" {
+			kitfile.Model.Version = dc.ModelVersion
+		}
 	} else {
 		output.Logf(output.LogLevelTrace, "No model detected; adding metadata files as dataset layers")
-		for _, metadataPath := range metadataPaths {
+		for _, metadataPath := range dc.MetadataPaths {
 			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: metadataPath})
 		}
 	}
 
 	// Decide how to handle remaining paths. Either package them in one large code layer with basePath
 	// or as separate layers for each directory.
-	output.Logf(output.LogLevelTrace, "Unable to process %d paths in %s", len(unprocessedDirPaths), baseDir)
-	if includeCatchallSection || len(unprocessedDirPaths) > 5 {
-		output.Logf(output.LogLevelTrace, "Adding catch-all code layer to include files in %s", baseDir)
+	output.Logf(output.LogLevelTrace, "Unable to process %d paths in %s", len(dc.CodePaths), dc.BaseDir)
+	if dc.UseCatchall || len(dc.CodePaths) > 5 {
+		output.Logf(output.LogLevelTrace, "Adding catch-all code layer to include files in %s", dc.BaseDir)
 		// Overwrite any code layers we added before; this is cleaner than e.g. having a layer for '.' and a layer for 'src'
 		kitfile.Code = []artifact.Code{{Path: "."}}
 	} else {
-		for _, path := range unprocessedDirPaths {
-			kitfile.Code = append(kitfile.Code, artifact.Code{Path: path})
+		for _, cp := range dc.CodePaths {
+			kitfile.Code = append(kitfile.Code, artifact.Code{Path: cp.Path, License: cp.License})
 		}
 	}
 
-	// If we detected a license, try to attach it to the Kitfile section that makes sense
-	if kitfile.Model != nil && detectedLicenseType != "" {
-		kitfile.Model.License = detectedLicenseType
-	} else if len(kitfile.DataSets) == 1 {
-		kitfile.DataSets[0].License = detectedLicenseType
-	} else if len(kitfile.Code) == 1 {
-		kitfile.Code[0].License = detectedLicenseType
-	} else {
-		output.Logf(output.LogLevelTrace, "Unsure what license applies to, adding to Kitfile package")
-		kitfile.Package.License = detectedLicenseType
+	// If we detected a root-level license, attach it to the Kitfile section
+	// that makes sense, but don't clobber a license already attached to a
+	// more specific layer (e.g. a dataset subdirectory with its own
+	// LICENSE file).
+	if dc.DetectedLicense != "" {
+		switch {
+		case kitfile.Model != nil && kitfile.Model.License == "":
+			kitfile.Model.License = dc.DetectedLicense
+		case len(kitfile.DataSets) == 1 && kitfile.DataSets[0].License == "":
+			kitfile.DataSets[0].License = dc.DetectedLicense
+		case len(kitfile.Code) == 1 && kitfile.Code[0].License == "":
+			kitfile.Code[0].License = dc.DetectedLicense
+		case kitfile.Model == nil && len(kitfile.DataSets) != 1 && len(kitfile.Code) != 1:
+			output.Logf(output.LogLevelTrace, "Unsure what license applies to, adding to Kitfile package")
+			kitfile.Package.License = dc.DetectedLicense
+		}
 	}
 
 	return kitfile, nil
 }
 
-func addDirToKitfile(kitfile *artifact.KitFile, baseDir, dirPath string, d fs.DirEntry) (modelFiles []string, err error) {
-	switch d.Name() {
-	case "docs":
-		output.Logf(output.LogLevelTrace, "Directory %s interpreted as documentation", d.Name())
-		kitfile.Docs = append(kitfile.Docs, artifact.Docs{
-			Path: dirPath,
-		})
-		return nil, nil
-	case "src", "pkg", "lib", "build":
-		output.Logf(output.LogLevelTrace, "Directory %s interpreted as code", d.Name())
-		kitfile.Code = append(kitfile.Code, artifact.Code{
-			Path: dirPath,
-		})
-		return nil, nil
-	}
-
-	entries, err := os.ReadDir(filepath.Join(baseDir, dirPath))
+// GenerateKitfile generates a basic Kitfile by looking at the contents of a
+// directory. Parameter packageOpt can be used to define metadata for the
+// Kitfile (i.e. the package section), which is left empty if the parameter
+// is nil.
+func GenerateKitfile(baseDir string, packageOpt *artifact.Package) (*artifact.KitFile, error) {
+	dc, err := ClassifyDirectory(baseDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %w", dirPath, err)
-	}
-
-	// Sort entries in the directory to try and figure out what it contains. We'll reuse the
-	// fact that the fileTypes are enumerated using iota (and so are ints) to index correctly.
-	// Avoid using maps here since they iterate in a random order.
-	directoryContents := [int(fileTypeUnknown) + 1][]string{}
-	for _, entry := range entries {
-		relPath := filepath.Join(dirPath, entry.Name())
-		if entry.IsDir() {
-			// TODO: we can potentially recurse further here if we find we need to
-			directoryContents[int(fileTypeUnknown)] = append(directoryContents[int(fileTypeUnknown)], relPath)
-			continue
-		}
-		fileType := determineFileType(entry.Name())
-		if fileType == fileTypeModel {
-			modelFiles = append(modelFiles, relPath)
-		}
-		directoryContents[int(fileType)] = append(directoryContents[int(fileType)], relPath)
-	}
-
-	// Try to detect directories that contain e.g. only datasets so we can add them
-	overallFiletype := fileTypeUnknown
-	directoryHasMixedContents := false
-	for fType, files := range directoryContents {
-		if len(files) > 0 && fileType(fType) != fileTypeMetadata {
-			if overallFiletype != fileTypeUnknown {
-				output.Logf(output.LogLevelTrace, "Detected mixed contents within directory %s", dirPath)
-				directoryHasMixedContents = true
-			}
-			overallFiletype = fileType(fType)
-		}
-	}
-	if directoryHasMixedContents {
-		return modelFiles, fmt.Errorf("mixed content in directory; unable to determine type")
-	}
-	switch overallFiletype {
-	case fileTypeModel:
-		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a model directory", dirPath)
-		// Include any metadata files as modelParts later
-		modelFiles = append(modelFiles, directoryContents[int(fileTypeMetadata)]...)
-	case fileTypeDataset:
-		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a dataset directory", dirPath)
-		kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: dirPath})
-	case fileTypeDocs:
-		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a docs directory", dirPath)
-		kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: dirPath})
-	default:
-		output.Logf(output.LogLevelTrace, "Could not determine type for directory %s", dirPath)
-		// If it's overall code, metadata, or unknown, just return it as unprocessed and let it be added as a Code section
-		// later
-		return modelFiles, fmt.Errorf("directory should be handled as Code")
-	}
-
-	return modelFiles, nil
-}
-
-func determineFileType(filename string) fileType {
-	if anySuffix(filename, modelWeightsSuffixes) {
-		return fileTypeModel
+		return nil, err
 	}
-	// Metadata should be included in either Model or Datasets, depending on
-	// other contents
-	if anySuffix(filename, metadataSuffixes) {
-		return fileTypeMetadata
-	}
-	if anySuffix(filename, docsSuffixes) {
-		return fileTypeDocs
-	}
-	if anySuffix(filename, datasetSuffixes) {
-		return fileTypeDataset
-	}
-	return fileTypeUnknown
-
+	return BuildKitfile(dc, packageOpt)
 }
 
 func addModelToKitfile(kitfile *artifact.KitFile, baseDir string, modelPaths []string) error {
@@ -355,17 +355,13 @@ func addModelToKitfile(kitfile *artifact.KitFile, baseDir string, modelPaths []s
 	return nil
 }
 
-func detectLicense(licensePath string) (string, error) {
-	license, err := os.ReadFile(licensePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read license file: %w", err)
-	}
-	cov := licensecheck.Scan(license)
-	if len(cov.Match) == 1 {
-		return cov.Match[0].ID, nil
-	} else {
-		return "", fmt.Errorf("multiple licenses matched license file")
+// licenseCacheDir returns the directory used to cache the SPDX license
+// list, or "" (disabling caching) if configHome isn't set.
+func licenseCacheDir(configHome string) string {
+	if configHome == "" {
+		return ""
 	}
+	return filepath.Join(configHome, "licenses")
 }
 
 func anySuffix(query string, suffixes []string) bool {
@@ -0,0 +1,179 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ignore implements the common subset of .gitignore pattern
+// matching (comments, blank lines, "/"-anchored and directory-only
+// patterns, "*"/"?"/"**" wildcards, and "!" negation) so that kit init can
+// skip paths like ".git", "node_modules", or "__pycache__" the same way a
+// real checkout would, without pulling in a full git implementation. It
+// does not implement the entire gitignore spec (e.g. character classes, or
+// rules from ignore files outside the scanned directory's root).
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filenames are the ignore files Load looks for in a directory, applied in
+// order so that a later file's rules can override an earlier one's (e.g. a
+// project's .kitignore re-including something its .gitignore excludes).
+var Filenames = []string{".gitignore", ".kitignore"}
+
+// defaultRules are applied to every scan before any rules loaded from the
+// project's own .gitignore/.kitignore. Real projects virtually never list
+// these themselves (git doesn't need to ignore its own metadata directory),
+// so without a built-in default a plain checkout's .git directory would be
+// walked and dumped into the code catch-all like any other file. A
+// project's own ignore file can still override one of these with a
+// negated rule (e.g. "!.git/"), since Set.Match applies rules in order.
+var defaultRules = []rule{
+	{pattern: ".git", dirOnly: true},
+	{pattern: "node_modules", dirOnly: true},
+	{pattern: "__pycache__", dirOnly: true},
+}
+
+// Set is an ordered collection of ignore rules. Matching follows git's own
+// last-match-wins semantics: later rules (including "!"-negated ones)
+// override earlier ones for a given path.
+type Set struct {
+	rules []rule
+}
+
+type rule struct {
+	// pattern is slash-separated, with any leading "!" and trailing "/"
+	// already stripped.
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Load reads any of Filenames present directly within dir and returns the
+// combined Set. A missing file is not an error, since not having either is
+// the common case; Load only fails if a file exists but can't be read.
+func Load(dir string) (*Set, error) {
+	set := &Set{rules: append([]rule(nil), defaultRules...)}
+	for _, name := range Filenames {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		set.rules = append(set.rules, parseLines(string(data))...)
+	}
+	return set, nil
+}
+
+func parseLines(contents string) []rule {
+	var rules []rule
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.HasPrefix(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		} else if strings.Contains(line, "/") {
+			// A pattern with a slash anywhere but the end is matched
+			// against the full relative path, same as git.
+			r.anchored = true
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// Match reports whether relPath (slash-separated, relative to the
+// directory Load was called on) should be ignored. isDir indicates
+// whether relPath names a directory, since directory-only ("foo/") patterns
+// only apply to directories. A nil Set never matches anything.
+func (s *Set) Match(relPath string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	ignored := false
+	for _, r := range s.rules {
+		if r.matches(relPath, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r rule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return matchGlob(r.pattern, relPath)
+	}
+	// An unanchored pattern can match starting at any path component, not
+	// just the full path (e.g. "*.pyc" matches "build/obj/foo.pyc").
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		if matchGlob(r.pattern, strings.Join(segments[i:], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated gitignore pattern against a
+// slash-separated path, supporting "*"/"?" within a single path segment
+// and "**" as a stand-in for zero or more whole segments.
+func matchGlob(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pat, path []string) bool {
+	if len(pat) == 0 {
+		return len(path) == 0
+	}
+	if pat[0] == "**" {
+		if len(pat) == 1 {
+			return true
+		}
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pat[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, err := filepath.Match(pat[0], path[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pat[1:], path[1:])
+}
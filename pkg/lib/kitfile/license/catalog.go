@@ -0,0 +1,257 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package license scans files for license text and resolves them to SPDX
+// identifiers. It wraps google/licensecheck's builtin corpus with an
+// optional, cached copy of the canonical SPDX license list, and supports
+// returning a combined SPDX expression (e.g. "Apache-2.0 OR MIT") when more
+// than one license is matched with similar confidence.
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/licensecheck"
+)
+
+// DefaultThreshold is the minimum match coverage (as a percentage, 0-100)
+// for a license to be reported at all.
+const DefaultThreshold = 90.0
+
+// closeBand is how close (in coverage percentage points) two matches must
+// be to both be included in a combined SPDX expression.
+const closeBand = 5.0
+
+const spdxListURL = "https://raw.githubusercontent.com/spdx/license-list-data/main/json/licenses.json"
+
+// spdxListMaxAge is how long a cached copy of the SPDX license list is
+// considered fresh before Catalog re-downloads it.
+const spdxListMaxAge = 7 * 24 * time.Hour
+
+// spdxDownloadTimeout bounds how long downloadSPDXList waits for the SPDX
+// list, so that a slow or unreachable network turns a stale cache into a
+// quick failure (falling back to treating every id as known, see
+// knownSPDXID) rather than kit init hanging indefinitely on first run.
+const spdxDownloadTimeout = 10 * time.Second
+
+// Match is a single detected license within a scanned file.
+type Match struct {
+	SPDXID   string
+	Coverage float64
+}
+
+// Result is the outcome of scanning one candidate file (e.g. a LICENSE or
+// NOTICE file).
+type Result struct {
+	Path       string
+	Expression string
+	Matches    []Match
+}
+
+// Catalog scans files for license text and resolves matches to SPDX
+// identifiers, optionally backed by a cached copy of the SPDX license list.
+type Catalog struct {
+	cacheDir  string
+	threshold float64
+
+	spdxOnce sync.Once
+	spdxErr  error
+	spdxIDs  map[string]bool
+}
+
+// NewCatalog returns a Catalog that caches the SPDX license list under
+// cacheDir (typically "$configHome/licenses") and only reports matches at
+// or above threshold (a percentage, 0-100). A non-positive threshold uses
+// DefaultThreshold.
+func NewCatalog(cacheDir string, threshold float64) *Catalog {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Catalog{cacheDir: cacheDir, threshold: threshold}
+}
+
+// ScanFile scans a single file (e.g. a detected LICENSE/COPYING/NOTICE
+// file) and returns its matches and, if any are found, a combined SPDX
+// expression.
+func (c *Catalog) ScanFile(path string) (*Result, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license file: %w", err)
+	}
+	cov := licensecheck.Scan(contents)
+	matches := make([]Match, 0, len(cov.Match))
+	for _, m := range matchCoverage(cov) {
+		if !c.knownSPDXID(m.SPDXID) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return &Result{
+		Path:       path,
+		Expression: c.Expression(matches),
+		Matches:    matches,
+	}, nil
+}
+
+// matchCoverage approximates each match's individual coverage percentage by
+// splitting the Coverage's overall Percent proportionally across matches by
+// the length of text they each cover; licensecheck only reports Percent in
+// aggregate.
+func matchCoverage(cov licensecheck.Coverage) []Match {
+	totalSpan := 0
+	for _, m := range cov.Match {
+		totalSpan += m.End - m.Start
+	}
+	matches := make([]Match, 0, len(cov.Match))
+	for _, m := range cov.Match {
+		share := 0.0
+		if totalSpan > 0 {
+			share = float64(m.End-m.Start) / float64(totalSpan)
+		}
+		matches = append(matches, Match{SPDXID: m.ID, Coverage: share * cov.Percent})
+	}
+	return matches
+}
+
+// Expression combines matches into a single SPDX expression, e.g.
+// "Apache-2.0 OR MIT" when more than one license is matched with similar
+// confidence. It returns "" if no match meets the catalog's threshold.
+func (c *Catalog) Expression(matches []Match) string {
+	var confident []Match
+	for _, m := range matches {
+		if m.Coverage >= c.threshold {
+			confident = append(confident, m)
+		}
+	}
+	if len(confident) == 0 {
+		return ""
+	}
+	sort.Slice(confident, func(i, j int) bool { return confident[i].Coverage > confident[j].Coverage })
+
+	ids := []string{confident[0].SPDXID}
+	top := confident[0].Coverage
+	for _, m := range confident[1:] {
+		if top-m.Coverage > closeBand {
+			break
+		}
+		ids = append(ids, m.SPDXID)
+	}
+	return strings.Join(ids, " OR ")
+}
+
+// knownSPDXID reports whether id is a recognized SPDX license identifier,
+// downloading (and caching) the SPDX license list on first use. If the list
+// can't be loaded, every id is treated as known so that callers still get
+// the underlying licensecheck result.
+//
+// A single Catalog is shared across the concurrent goroutines that scan.go
+// spawns to classify sibling directories, so the lazy load is guarded by
+// sync.Once rather than a bare nil check.
+func (c *Catalog) knownSPDXID(id string) bool {
+	if err := c.ensureSPDXList(); err != nil {
+		return true
+	}
+	return c.spdxIDs[id]
+}
+
+func (c *Catalog) ensureSPDXList() error {
+	c.spdxOnce.Do(func() {
+		c.spdxIDs, c.spdxErr = loadSPDXList(c.cacheDir)
+	})
+	return c.spdxErr
+}
+
+func loadSPDXList(cacheDir string) (map[string]bool, error) {
+	if cacheDir == "" {
+		return nil, fmt.Errorf("no cache directory configured")
+	}
+	cachePath := filepath.Join(cacheDir, "spdx-licenses.json")
+	data, err := readCacheIfFresh(cachePath, spdxListMaxAge)
+	if err != nil {
+		data, err = downloadSPDXList()
+		if err != nil {
+			return nil, err
+		}
+		_ = writeCache(cachePath, data)
+	}
+
+	var list struct {
+		Licenses []struct {
+			LicenseID string `json:"licenseId"`
+		} `json:"licenses"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX license list: %w", err)
+	}
+	ids := make(map[string]bool, len(list.Licenses))
+	for _, l := range list.Licenses {
+		ids[l.LicenseID] = true
+	}
+	return ids, nil
+}
+
+func readCacheIfFresh(path string, maxAge time.Duration) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > maxAge {
+		return nil, fmt.Errorf("cached SPDX license list is stale")
+	}
+	return os.ReadFile(path)
+}
+
+func writeCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// IsLicenseFilename reports whether name looks like a license, copying, or
+// notice file, regardless of extension.
+func IsLicenseFilename(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "license") || strings.HasPrefix(lower, "copying") || strings.HasPrefix(lower, "notice")
+}
+
+func downloadSPDXList() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), spdxDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spdxListURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download SPDX license list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download SPDX license list: status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitfile
+
+import (
+	"encoding/json"
+	"kitops/pkg/artifact"
+	"kitops/pkg/output"
+	"os"
+	"path/filepath"
+)
+
+// hfConfig is the subset of a HuggingFace config.json this package cares
+// about; the full file has many more model-specific fields.
+type hfConfig struct {
+	ModelType           string   `json:"model_type"`
+	Architectures       []string `json:"architectures"`
+	TransformersVersion string   `json:"transformers_version"`
+}
+
+type hfTokenizerConfig struct {
+	TokenizerClass string `json:"tokenizer_class"`
+}
+
+type hfGenerationConfig struct {
+	TransformersVersion string `json:"transformers_version"`
+}
+
+// detectModelMetadata inspects sidecar files (HuggingFace config.json and
+// friends) alongside a detected model, plus the model file's own header
+// (GGUF, safetensors, ONNX), to enrich its Framework, Version, and
+// Parameters fields. Detection failures are non-fatal: the model is still
+// usable without this metadata, so errors are only logged at trace/debug
+// level.
+func detectModelMetadata(baseDir string, model *artifact.Model, modelFiles []string) {
+	dirs := map[string]bool{filepath.Dir(model.Path): true}
+	for _, path := range modelFiles {
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		entries, err := os.ReadDir(filepath.Join(baseDir, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			applySidecarMetadata(filepath.Join(baseDir, dir, entry.Name()), entry.Name(), model)
+		}
+	}
+
+	if err := applyModelFileHeader(filepath.Join(baseDir, model.Path), model); err != nil {
+		output.Logf(output.LogLevelTrace, "No recognized header metadata in %s: %s", model.Path, err)
+	}
+}
+
+func applySidecarMetadata(fullPath, filename string, model *artifact.Model) {
+	switch filename {
+	case "config.json":
+		var cfg hfConfig
+		if err := readJSONFile(fullPath, &cfg); err != nil {
+			output.Debugf("Error reading HuggingFace config %s: %s", fullPath, err)
+			return
+		}
+		if model.Framework == "" {
+			if cfg.ModelType != "" {
+				model.Framework = cfg.ModelType
+			} else if len(cfg.Architectures) > 0 {
+				model.Framework = cfg.Architectures[0]
+			}
+		}
+		if model.Version == "" {
+			model.Version = cfg.TransformersVersion
+		}
+	case "tokenizer_config.json":
+		var cfg hfTokenizerConfig
+		if err := readJSONFile(fullPath, &cfg); err != nil {
+			output.Debugf("Error reading tokenizer config %s: %s", fullPath, err)
+			return
+		}
+		if model.Framework == "" {
+			model.Framework = cfg.TokenizerClass
+		}
+	case "generation_config.json":
+		var cfg hfGenerationConfig
+		if err := readJSONFile(fullPath, &cfg); err != nil {
+			output.Debugf("Error reading generation config %s: %s", fullPath, err)
+			return
+		}
+		if model.Version == "" {
+			model.Version = cfg.TransformersVersion
+		}
+	}
+}
+
+func readJSONFile(path string, v any) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
+
+// applyModelFileHeader reads a model file's own header to detect its
+// format, dispatching to the parser for whichever of GGUF, safetensors, or
+// ONNX it recognizes. Returns an error if the file doesn't match any known
+// header.
+func applyModelFileHeader(path string, model *artifact.Model) error {
+	switch {
+	case hasSuffix(path, ".gguf", ".ggml", ".ggmf"):
+		return applyGGUFHeader(path, model)
+	case hasSuffix(path, ".safetensors"):
+		return applySafetensorsHeader(path, model)
+	case hasSuffix(path, ".onnx"):
+		return applyONNXHeader(path, model)
+	default:
+		return errUnrecognizedModelHeader
+	}
+}
+
+func hasSuffix(path string, suffixes ...string) bool {
+	return anySuffix(path, suffixes)
+}
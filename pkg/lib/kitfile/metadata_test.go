@@ -0,0 +1,94 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitfile
+
+import (
+	"kitops/pkg/artifact"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplySidecarMetadataHuggingFaceConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{"model_type": "llama", "transformers_version": "4.40.0"}`)
+
+	model := &artifact.Model{}
+	applySidecarMetadata(path, "config.json", model)
+
+	if model.Framework != "llama" {
+		t.Errorf("expected Framework %q, got %q", "llama", model.Framework)
+	}
+	if model.Version != "4.40.0" {
+		t.Errorf("expected Version %q, got %q", "4.40.0", model.Version)
+	}
+}
+
+func TestApplySidecarMetadataFallsBackToArchitectures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{"architectures": ["LlamaForCausalLM"]}`)
+
+	model := &artifact.Model{}
+	applySidecarMetadata(path, "config.json", model)
+
+	if model.Framework != "LlamaForCausalLM" {
+		t.Errorf("expected Framework %q, got %q", "LlamaForCausalLM", model.Framework)
+	}
+}
+
+func TestApplySidecarMetadataDoesNotOverwriteExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokenizer_config.json")
+	writeFile(t, path, `{"tokenizer_class": "LlamaTokenizer"}`)
+
+	model := &artifact.Model{Framework: "pytorch"}
+	applySidecarMetadata(path, "tokenizer_config.json", model)
+
+	if model.Framework != "pytorch" {
+		t.Errorf("expected existing Framework to be preserved, got %q", model.Framework)
+	}
+}
+
+func TestApplyModelFileHeaderDispatch(t *testing.T) {
+	model := &artifact.Model{}
+	if err := applyModelFileHeader(filepath.Join("testdata", "fixtures", "valid.gguf"), model); err != nil {
+		t.Fatalf("applyModelFileHeader returned error: %s", err)
+	}
+	if model.Framework != "llama" {
+		t.Errorf("expected Framework %q, got %q", "llama", model.Framework)
+	}
+}
+
+func TestApplyModelFileHeaderUnrecognized(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.bin")
+	writeFile(t, path, "not a recognized header")
+
+	model := &artifact.Model{}
+	if err := applyModelFileHeader(path, model); err != errUnrecognizedModelHeader {
+		t.Errorf("expected errUnrecognizedModelHeader, got %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write fixture file: %s", err)
+	}
+}
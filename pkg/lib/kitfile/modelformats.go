@@ -0,0 +1,445 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/kitfile/classify"
+	"os"
+)
+
+var errUnrecognizedModelHeader = errors.New("file does not match any known model header format")
+
+// ggufValueType is the type tag for a single GGUF key-value metadata entry.
+// See https://github.com/ggerganov/ggml/blob/master/docs/gguf.md.
+type ggufValueType uint32
+
+const (
+	ggufUint8 ggufValueType = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+const ggufMagic = "GGUF"
+
+// ggufMaxStringLen bounds the length prefix read for any GGUF string (keys,
+// string values, and string array elements); sanity bound, real strings are
+// at most a few KB.
+const ggufMaxStringLen = 100 * 1024 * 1024
+
+// applyGGUFHeader reads the GGUF magic and key-value metadata block of a
+// GGUF model file and extracts general.architecture and
+// general.parameter_count, if present, without reading the tensor data that
+// follows.
+func applyGGUFHeader(path string, model *artifact.Model) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic) != ggufMagic {
+		return fmt.Errorf("not a GGUF file")
+	}
+
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	var tensorCount, kvCount uint64
+	if err := binary.Read(r, binary.LittleEndian, &tensorCount); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &kvCount); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := readGGUFString(r)
+		if err != nil {
+			return err
+		}
+		var rawType uint32
+		if err := binary.Read(r, binary.LittleEndian, &rawType); err != nil {
+			return err
+		}
+		valType := ggufValueType(rawType)
+
+		switch key {
+		case "general.architecture":
+			val, err := readGGUFString(r)
+			if err != nil {
+				return err
+			}
+			if model.Framework == "" {
+				model.Framework = val
+			}
+		case "general.parameter_count":
+			val, err := readGGUFUint(r, valType)
+			if err != nil {
+				return err
+			}
+			if model.Parameters == "" {
+				model.Parameters = formatParameterCount(val)
+			}
+		default:
+			if err := skipGGUFValue(r, valType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readGGUFString(r io.Reader) (string, error) {
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	if length > ggufMaxStringLen {
+		return "", fmt.Errorf("implausible GGUF string length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readGGUFUint reads a single scalar GGUF value of the given type and
+// returns it widened to uint64; it is only called for numeric types.
+func readGGUFUint(r io.Reader, valType ggufValueType) (uint64, error) {
+	switch valType {
+	case ggufUint8:
+		var v uint8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufInt8:
+		var v int8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufUint16:
+		var v uint16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufInt16:
+		var v int16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufUint32:
+		var v uint32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufInt32:
+		var v int32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufUint64:
+		var v uint64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case ggufInt64:
+		var v int64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	default:
+		return 0, fmt.Errorf("unexpected GGUF value type %d for numeric field", valType)
+	}
+}
+
+// skipGGUFValue advances past a single GGUF value of the given type without
+// interpreting it, including nested array elements.
+func skipGGUFValue(r io.Reader, valType ggufValueType) error {
+	switch valType {
+	case ggufUint8, ggufInt8, ggufBool:
+		_, err := io.CopyN(io.Discard, r, 1)
+		return err
+	case ggufUint16, ggufInt16:
+		_, err := io.CopyN(io.Discard, r, 2)
+		return err
+	case ggufUint32, ggufInt32, ggufFloat32:
+		_, err := io.CopyN(io.Discard, r, 4)
+		return err
+	case ggufUint64, ggufInt64, ggufFloat64:
+		_, err := io.CopyN(io.Discard, r, 8)
+		return err
+	case ggufString:
+		_, err := readGGUFString(r)
+		return err
+	case ggufArray:
+		var elemType uint32
+		if err := binary.Read(r, binary.LittleEndian, &elemType); err != nil {
+			return err
+		}
+		var count uint64
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return err
+		}
+		for i := uint64(0); i < count; i++ {
+			if err := skipGGUFValue(r, ggufValueType(elemType)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown GGUF value type %d", valType)
+	}
+}
+
+// formatParameterCount renders a raw parameter count using the same
+// shorthand (e.g. "7B", "13M") commonly used to name model checkpoints.
+func formatParameterCount(n uint64) string {
+	switch {
+	case n >= 1_000_000_000:
+		return fmt.Sprintf("%.1fB", float64(n)/1_000_000_000)
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fK", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// applySafetensorsHeader reads a .safetensors file's header: the first 8
+// bytes are a little-endian header length, followed by a JSON object whose
+// "__metadata__" key (if present) holds framework information.
+func applySafetensorsHeader(path string, model *artifact.Model) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var headerLen uint64
+	if err := binary.Read(f, binary.LittleEndian, &headerLen); err != nil {
+		return err
+	}
+	if headerLen == 0 || headerLen > classify.SafetensorsMaxHeaderLen {
+		return fmt.Errorf("implausible safetensors header length %d", headerLen)
+	}
+	headerBytes := make([]byte, headerLen)
+	if _, err := io.ReadFull(f, headerBytes); err != nil {
+		return err
+	}
+
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return err
+	}
+	metaRaw, ok := header["__metadata__"]
+	if !ok {
+		return nil
+	}
+	var meta map[string]string
+	if err := json.Unmarshal(metaRaw, &meta); err != nil {
+		return err
+	}
+	if model.Framework == "" {
+		if format, ok := meta["format"]; ok {
+			model.Framework = safetensorsFormatName(format)
+		}
+	}
+	return nil
+}
+
+func safetensorsFormatName(format string) string {
+	switch format {
+	case "pt":
+		return "pytorch"
+	case "tf":
+		return "tensorflow"
+	case "np":
+		return "numpy"
+	case "jax":
+		return "jax"
+	default:
+		return format
+	}
+}
+
+// onnxMaxHeaderScan bounds how much of an .onnx file is read; the fields we
+// care about (producer_name, opset_import) are always near the start of the
+// serialized ModelProto.
+const onnxMaxHeaderScan = 64 * 1024
+
+// applyONNXHeader reads just enough of an ONNX ModelProto's protobuf
+// encoding to extract the producer name and opset version, without
+// depending on the generated ONNX/protobuf schema.
+func applyONNXHeader(path string, model *artifact.Model) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(io.LimitReader(f, onnxMaxHeaderScan))
+
+	var producer string
+	var opsetVersion int64
+	for {
+		tag, wireType, err := readProtoTag(r)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		switch {
+		case tag == 2 && wireType == 2: // producer_name
+			s, err := readProtoString(r)
+			if err != nil {
+				return err
+			}
+			producer = s
+		case tag == 8 && wireType == 2: // opset_import (repeated OperatorSetIdProto)
+			msg, err := readProtoBytes(r)
+			if err != nil {
+				return err
+			}
+			if v, ok := parseOpsetVersion(msg); ok {
+				opsetVersion = v
+			}
+		default:
+			if err := skipProtoField(r, wireType); err != nil {
+				return err
+			}
+		}
+	}
+
+	if producer == "" && opsetVersion == 0 {
+		return errUnrecognizedModelHeader
+	}
+	if model.Framework == "" && producer != "" {
+		model.Framework = producer
+	}
+	if model.Version == "" && opsetVersion > 0 {
+		model.Version = fmt.Sprintf("opset %d", opsetVersion)
+	}
+	return nil
+}
+
+func readVarint(r io.ByteReader) (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+}
+
+func readProtoTag(r io.ByteReader) (tag int, wireType int, err error) {
+	v, err := readVarint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 0x7), nil
+}
+
+func readProtoBytes(r *bufio.Reader) ([]byte, error) {
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > onnxMaxHeaderScan {
+		return nil, fmt.Errorf("implausible protobuf field length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readProtoString(r *bufio.Reader) (string, error) {
+	buf, err := readProtoBytes(r)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// skipProtoField advances past a single protobuf field's value given its
+// wire type. Wire types 3/4 (deprecated start/end group) aren't supported.
+func skipProtoField(r *bufio.Reader, wireType int) error {
+	switch wireType {
+	case 0: // varint
+		_, err := readVarint(r)
+		return err
+	case 1: // 64-bit
+		_, err := io.CopyN(io.Discard, r, 8)
+		return err
+	case 2: // length-delimited
+		_, err := readProtoBytes(r)
+		return err
+	case 5: // 32-bit
+		_, err := io.CopyN(io.Discard, r, 4)
+		return err
+	default:
+		return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+	}
+}
+
+// parseOpsetVersion extracts the "version" field (field 2, varint) from a
+// serialized OperatorSetIdProto message.
+func parseOpsetVersion(msg []byte) (int64, bool) {
+	r := bufio.NewReader(bytes.NewReader(msg))
+	for {
+		tag, wireType, err := readProtoTag(r)
+		if err != nil {
+			return 0, false
+		}
+		if tag == 2 && wireType == 0 {
+			v, err := readVarint(r)
+			if err != nil {
+				return 0, false
+			}
+			return int64(v), true
+		}
+		if err := skipProtoField(r, wireType); err != nil {
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitfile
+
+import (
+	"kitops/pkg/artifact"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyGGUFHeader(t *testing.T) {
+	model := &artifact.Model{}
+	if err := applyGGUFHeader(filepath.Join("testdata", "fixtures", "valid.gguf"), model); err != nil {
+		t.Fatalf("applyGGUFHeader returned error: %s", err)
+	}
+	if model.Framework != "llama" {
+		t.Errorf("expected Framework %q, got %q", "llama", model.Framework)
+	}
+	if model.Parameters != "7.0B" {
+		t.Errorf("expected Parameters %q, got %q", "7.0B", model.Parameters)
+	}
+}
+
+func TestApplyGGUFHeaderRejectsImplausibleStringLength(t *testing.T) {
+	model := &artifact.Model{}
+	err := applyGGUFHeader(filepath.Join("testdata", "fixtures", "truncated.gguf"), model)
+	if err == nil {
+		t.Fatal("expected error for implausible GGUF string length, got nil")
+	}
+}
+
+func TestApplySafetensorsHeader(t *testing.T) {
+	model := &artifact.Model{}
+	if err := applySafetensorsHeader(filepath.Join("testdata", "fixtures", "valid.safetensors"), model); err != nil {
+		t.Fatalf("applySafetensorsHeader returned error: %s", err)
+	}
+	if model.Framework != "pytorch" {
+		t.Errorf("expected Framework %q, got %q", "pytorch", model.Framework)
+	}
+}
+
+func TestApplyONNXHeader(t *testing.T) {
+	model := &artifact.Model{}
+	if err := applyONNXHeader(filepath.Join("testdata", "fixtures", "valid.onnx"), model); err != nil {
+		t.Fatalf("applyONNXHeader returned error: %s", err)
+	}
+	if model.Framework != "pytorch" {
+		t.Errorf("expected Framework %q, got %q", "pytorch", model.Framework)
+	}
+	if model.Version != "opset 17" {
+		t.Errorf("expected Version %q, got %q", "opset 17", model.Version)
+	}
+}
+
+func TestFormatParameterCount(t *testing.T) {
+	cases := []struct {
+		n    uint64
+		want string
+	}{
+		{500, "500"},
+		{1_500, "1.5K"},
+		{1_500_000, "1.5M"},
+		{7_000_000_000, "7.0B"},
+	}
+	for _, tc := range cases {
+		if got := formatParameterCount(tc.n); got != tc.want {
+			t.Errorf("formatParameterCount(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitfile
+
+import (
+	"bytes"
+	"fmt"
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/kitfile/classify"
+	"kitops/pkg/lib/kitfile/ignore"
+	"kitops/pkg/lib/kitfile/license"
+	"kitops/pkg/output"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentScans bounds how many directories are classified
+// concurrently during a recursive scan, so that wide trees don't exhaust
+// file descriptors or spawn unbounded goroutines.
+const maxConcurrentScans = 8
+
+// largeBinaryDirThreshold is the total size of unrecognized files within a
+// single directory above which, if most of that content looks like binary
+// data, the directory is treated as a dataset rather than falling into the
+// code catch-all.
+const largeBinaryDirThreshold = 10 * 1024 * 1024 // 10MB
+
+// largeBinaryDirBinaryFraction is the fraction (0-1) of a directory's
+// unrecognized file bytes that must look like binary data for the
+// large-binary heuristic in determineDominant to reclassify it as a
+// dataset.
+const largeBinaryDirBinaryFraction = 0.5
+
+// ModelFileInfo describes a single file classified as part of a model,
+// along with its size, so that the largest file in a multi-part model can
+// be picked out later without a second stat pass.
+type ModelFileInfo struct {
+	Path string
+	Size int64
+	// Framework is set when the file was classified by a user-defined rule
+	// (see the classify package) that specified one; it's empty for files
+	// classified by kit init's built-in rules.
+	Framework string
+}
+
+// ClassifiedPath is a path classified as a single Dataset or Code layer,
+// along with the license (if any) detected in its own subtree.
+type ClassifiedPath struct {
+	Path    string
+	License string
+}
+
+// UnknownFileInfo describes a file that couldn't be classified, along with
+// enough information for the large-binary-directory heuristic in
+// determineDominant to reconsider the directory it's part of as a dataset
+// rather than a code catch-all.
+type UnknownFileInfo struct {
+	Path     string
+	Size     int64
+	IsBinary bool
+}
+
+// SkippedPath records a path that was excluded from the generated Kitfile
+// rather than classified, along with why, so that callers can summarize
+// what was left out.
+type SkippedPath struct {
+	Path   string
+	Reason string
+}
+
+// DirClassification is the result of recursively classifying a single
+// directory and its subtree. classifyTree returns one of these per
+// directory; the caller merges each child's classification into its own.
+type DirClassification struct {
+	Path string
+
+	// Dominant is the role that makes up the subtree, or classify.RoleUnknown
+	// if Mixed is set.
+	Dominant classify.Role
+	Mixed    bool
+
+	// License is the SPDX expression detected from LICENSE/COPYING/NOTICE
+	// files found directly within this directory (not inherited from
+	// subdirectories), so that it can be attached to whichever layer this
+	// subtree ends up becoming.
+	License string
+
+	// ModelLicense is the nearest license detected for this subtree's model
+	// content: License itself if this directory has one, otherwise the
+	// ModelLicense bubbled up from whichever model-dominant child was merged
+	// in first. Unlike DatasetPaths/CodePaths, model files from every
+	// matching subtree are flattened into a single ModelFiles list, so there
+	// is nowhere else to attach a per-subtree license.
+	ModelLicense string
+
+	ModelFiles        []ModelFileInfo
+	MetadataPaths     []string
+	DatasetPaths      []ClassifiedPath
+	DocFiles          []string
+	LicenseCandidates []string
+	CodePaths         []ClassifiedPath
+	UnknownFiles      []UnknownFileInfo
+	SkippedPaths      []SkippedPath
+}
+
+// classifyTree recursively classifies dirPath (given relative to baseDir),
+// fanning out over subdirectories with a bounded worker pool shared via sem.
+// It applies the same special-cased-then-registry-based classification used
+// for a single directory at every depth, merging results bottom-up so that
+// e.g. a "checkpoints/step-1000/*.safetensors" tree is recognized as a model
+// directory rather than falling back to a catch-all. catalog is used to
+// attach a license to this directory, if it contains one of its own,
+// registry decides the role of each file within it, ignoreSet excludes
+// paths matched by .gitignore/.kitignore, and maxCodeSize (if positive)
+// excludes unrecognized files above that size from the code catch-all.
+func classifyTree(baseDir, dirPath string, sem chan struct{}, catalog *license.Catalog, registry *classify.Registry, ignoreSet *ignore.Set, maxCodeSize int64) (*DirClassification, error) {
+	switch filepath.Base(dirPath) {
+	case "docs":
+		output.Logf(output.LogLevelTrace, "Directory %s interpreted as documentation", dirPath)
+		return &DirClassification{Path: dirPath, Dominant: classify.RoleDocs, DocFiles: []string{dirPath}}, nil
+	case "src", "pkg", "lib", "build":
+		output.Logf(output.LogLevelTrace, "Directory %s interpreted as code", dirPath)
+		return &DirClassification{Path: dirPath, Dominant: classify.RoleCode, CodePaths: []ClassifiedPath{{Path: dirPath}}}, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(baseDir, dirPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dirPath, err)
+	}
+
+	dc := &DirClassification{Path: dirPath}
+	var subdirs []os.DirEntry
+	for _, entry := range entries {
+		relPath := filepath.Join(dirPath, entry.Name())
+		if ignoreSet.Match(relPath, entry.IsDir()) {
+			output.Logf(output.LogLevelTrace, "Skipping %s: matched by .gitignore/.kitignore", relPath)
+			dc.SkippedPaths = append(dc.SkippedPaths, SkippedPath{Path: relPath, Reason: "matched by .gitignore/.kitignore"})
+			continue
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, entry)
+			continue
+		}
+		classifyFileInto(dc, baseDir, dirPath, entry, registry, maxCodeSize)
+	}
+	dc.License = scanLocalLicenses(baseDir, dc.LicenseCandidates, catalog)
+	dc.ModelLicense = dc.License
+
+	children := make([]*DirClassification, len(subdirs))
+	var g errgroup.Group
+	for i, entry := range subdirs {
+		i, entry := i, entry
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			childPath := filepath.Join(dirPath, entry.Name())
+			child, err := classifyTree(baseDir, childPath, sem, catalog, registry, ignoreSet, maxCodeSize)
+			if err != nil {
+				output.Logf(output.LogLevelTrace, "Failed to read directory %s: %s", childPath, err)
+				child = &DirClassification{Path: childPath, Dominant: classify.RoleCode, CodePaths: []ClassifiedPath{{Path: childPath}}}
+			}
+			children[i] = child
+			return nil
+		})
+	}
+	// Per-directory read errors are absorbed into a code-catchall child
+	// above rather than failing the whole scan, so g.Wait() never errors.
+	_ = g.Wait()
+
+	for _, child := range children {
+		mergeDirClassification(dc, child)
+	}
+	determineDominant(dc)
+
+	return dc, nil
+}
+
+// scanLocalLicenses scans the LICENSE/COPYING/NOTICE-like files found
+// directly within a directory and returns the expression for whichever one
+// has the highest-confidence match, or "" if none meet the catalog's
+// threshold.
+func scanLocalLicenses(baseDir string, candidates []string, catalog *license.Catalog) string {
+	best := ""
+	bestCoverage := 0.0
+	for _, relPath := range candidates {
+		result, err := catalog.ScanFile(filepath.Join(baseDir, relPath))
+		if err != nil || result.Expression == "" {
+			continue
+		}
+		coverage := 0.0
+		for _, m := range result.Matches {
+			if m.Coverage > coverage {
+				coverage = m.Coverage
+			}
+		}
+		if coverage > bestCoverage {
+			bestCoverage = coverage
+			best = result.Expression
+		}
+	}
+	return best
+}
+
+// classifyFileInto classifies a single file entry and appends it to the
+// appropriate bucket on dc. maxCodeSize, if positive, excludes
+// unrecognized files above that size from the code catch-all entirely,
+// recording them as skipped instead.
+func classifyFileInto(dc *DirClassification, baseDir, dirPath string, entry os.DirEntry, registry *classify.Registry, maxCodeSize int64) {
+	filename := entry.Name()
+	relPath := filepath.Join(dirPath, filename)
+	if license.IsLicenseFilename(filename) {
+		dc.LicenseCandidates = append(dc.LicenseCandidates, relPath)
+		return
+	}
+
+	size := int64(0)
+	if info, err := entry.Info(); err == nil {
+		size = info.Size()
+	}
+
+	result := registry.Classify(filepath.Join(baseDir, relPath), relPath)
+	switch result.Role {
+	case classify.RoleModel:
+		dc.ModelFiles = append(dc.ModelFiles, ModelFileInfo{Path: relPath, Size: size, Framework: result.Framework})
+	case classify.RoleMetadata:
+		dc.MetadataPaths = append(dc.MetadataPaths, relPath)
+	case classify.RoleDocs:
+		dc.DocFiles = append(dc.DocFiles, relPath)
+	case classify.RoleDataset:
+		dc.DatasetPaths = append(dc.DatasetPaths, ClassifiedPath{Path: relPath})
+	case classify.RoleIgnore:
+		output.Logf(output.LogLevelTrace, "Ignoring file %s", relPath)
+	default:
+		if maxCodeSize > 0 && size > maxCodeSize {
+			output.Logf(output.LogLevelTrace, "Skipping %s: exceeds --max-code-size (%d bytes)", relPath, size)
+			dc.SkippedPaths = append(dc.SkippedPaths, SkippedPath{Path: relPath, Reason: "exceeds --max-code-size"})
+			return
+		}
+		dc.UnknownFiles = append(dc.UnknownFiles, UnknownFileInfo{
+			Path:     relPath,
+			Size:     size,
+			IsBinary: looksBinary(filepath.Join(baseDir, relPath)),
+		})
+	}
+}
+
+// looksBinary reports whether the file at path appears to hold binary
+// (non-text) data, using the same heuristic git itself uses: a NUL byte
+// within the first chunk of the file.
+func looksBinary(path string) bool {
+	return bytes.IndexByte(classify.ReadHeader(path), 0) != -1
+}
+
+// determineDominant tallies the buckets populated on dc (ignoring metadata,
+// which can belong to either a model or a dataset) and sets dc.Dominant. If
+// more than one bucket is populated, dc.Mixed is set instead. Finally, it
+// applies the large-binary-directory heuristic: a directory that would
+// otherwise fall into the code catch-all, but whose unrecognized content is
+// large and mostly binary, is treated as a dataset instead.
+func determineDominant(dc *DirClassification) {
+	counts := []struct {
+		role  classify.Role
+		count int
+	}{
+		{classify.RoleModel, len(dc.ModelFiles)},
+		{classify.RoleDataset, len(dc.DatasetPaths)},
+		{classify.RoleDocs, len(dc.DocFiles)},
+		{classify.RoleCode, len(dc.CodePaths)},
+		{classify.RoleUnknown, len(dc.UnknownFiles)},
+	}
+	seen := false
+	for _, c := range counts {
+		if c.count == 0 {
+			continue
+		}
+		if seen {
+			dc.Mixed = true
+			return
+		}
+		dc.Dominant = c.role
+		seen = true
+	}
+	if !seen {
+		dc.Dominant = classify.RoleUnknown
+		return
+	}
+	applyLargeBinaryHeuristic(dc)
+}
+
+// applyLargeBinaryHeuristic reclassifies a directory whose only content is
+// unrecognized files as a dataset, rather than letting it fall into the
+// code catch-all, if that content is both large and mostly binary (e.g. a
+// directory of unlabeled checkpoint shards or raw tensors saved with an
+// unrecognized extension).
+func applyLargeBinaryHeuristic(dc *DirClassification) {
+	if dc.Dominant != classify.RoleUnknown || len(dc.UnknownFiles) == 0 {
+		return
+	}
+	var totalSize, binarySize int64
+	for _, f := range dc.UnknownFiles {
+		totalSize += f.Size
+		if f.IsBinary {
+			binarySize += f.Size
+		}
+	}
+	if totalSize < largeBinaryDirThreshold {
+		return
+	}
+	if float64(binarySize)/float64(totalSize) < largeBinaryDirBinaryFraction {
+		return
+	}
+	output.Logf(output.LogLevelTrace, "Directory %s dominated by large unrecognized binary content; treating as a dataset", dc.Path)
+	dc.Dominant = classify.RoleDataset
+}
+
+// mergeDirClassification folds a child directory's classification into its
+// parent. If the child has mixed contents (or an unrecognized type), it is
+// added to the parent's code paths as a single unit; otherwise the child's
+// contents are attributed to the parent according to the child's dominant
+// type, the same way a single level of classification always has.
+func mergeDirClassification(parent, child *DirClassification) {
+	parent.LicenseCandidates = append(parent.LicenseCandidates, child.LicenseCandidates...)
+	parent.SkippedPaths = append(parent.SkippedPaths, child.SkippedPaths...)
+
+	if child.Mixed {
+		output.Logf(output.LogLevelTrace, "Detected mixed contents within directory %s", child.Path)
+		parent.CodePaths = append(parent.CodePaths, ClassifiedPath{Path: child.Path, License: child.License})
+		return
+	}
+	switch child.Dominant {
+	case classify.RoleModel:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a model directory", child.Path)
+		parent.ModelFiles = append(parent.ModelFiles, child.ModelFiles...)
+		parent.MetadataPaths = append(parent.MetadataPaths, child.MetadataPaths...)
+		if parent.ModelLicense == "" {
+			parent.ModelLicense = child.ModelLicense
+		}
+	case classify.RoleDataset:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a dataset directory", child.Path)
+		parent.DatasetPaths = append(parent.DatasetPaths, ClassifiedPath{Path: child.Path, License: child.License})
+	case classify.RoleDocs:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a docs directory", child.Path)
+		parent.DocFiles = append(parent.DocFiles, child.Path)
+	default:
+		output.Logf(output.LogLevelTrace, "Could not determine type for directory %s", child.Path)
+		parent.CodePaths = append(parent.CodePaths, ClassifiedPath{Path: child.Path, License: child.License})
+	}
+}
+
+// mergeClassifiedDir folds a top-level directory's classification into the
+// DetectedContents being built for the base directory.
+func mergeClassifiedDir(dc *DetectedContents, child *DirClassification) {
+	dc.LicenseCandidates = append(dc.LicenseCandidates, child.LicenseCandidates...)
+	dc.SkippedPaths = append(dc.SkippedPaths, child.SkippedPaths...)
+
+	if child.Mixed {
+		output.Logf(output.LogLevelTrace, "Detected mixed contents within directory %s", child.Path)
+		dc.CodePaths = append(dc.CodePaths, ClassifiedPath{Path: child.Path, License: child.License})
+		return
+	}
+	switch child.Dominant {
+	case classify.RoleModel:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a model directory", child.Path)
+		for _, mf := range child.ModelFiles {
+			dc.ModelFiles = append(dc.ModelFiles, mf.Path)
+			if dc.ModelFramework == "" {
+				dc.ModelFramework = mf.Framework
+			}
+		}
+		dc.MetadataPaths = append(dc.MetadataPaths, child.MetadataPaths...)
+		if dc.ModelLicense == "" {
+			dc.ModelLicense = child.ModelLicense
+		}
+	case classify.RoleDataset:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a dataset directory", child.Path)
+		dc.Datasets = append(dc.Datasets, artifact.DataSet{Path: child.Path, License: child.License})
+	case classify.RoleDocs:
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a docs directory", child.Path)
+		dc.Docs = append(dc.Docs, artifact.Docs{Path: child.Path})
+	default:
+		output.Logf(output.LogLevelTrace, "Could not determine type for directory %s", child.Path)
+		dc.CodePaths = append(dc.CodePaths, ClassifiedPath{Path: child.Path, License: child.License})
+	}
+}